@@ -5,83 +5,255 @@
 // attributes, efficient buffer pooling, and zero-reflection hot paths.
 //
 // Example usage:
-// logger := slog.New(beautyhandler.New(os.Stdout, &slog.HandlerOptions{}))
+// logger := slog.New(beautyslog.New(os.Stdout, &beautyslog.Options{}))
 // logger.Info("hello", "user", "alice")
 package beautyslog
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"log/slog"
-	"reflect"
-	"runtime"
-	"strconv"
-	"strings"
+	"os"
 	"sync"
-	"time"
+	"sync/atomic"
 )
 
 const (
 	initialBufferSize = 512
 	maxBufferSize     = 4096
+
+	defaultTimeFormat = "15:04:05.999"
 )
 
-var (
-	colorReset  = []byte("\033[0m")
-	colorDebug  = []byte("\033[36m")
-	colorInfo   = []byte("\033[34m")
-	colorWarn   = []byte("\033[33m")
-	colorError  = []byte("\033[31m")
-	colorKey    = []byte("\033[32m")
-	colorValue  = []byte("\033[38;5;216m")
-	colorTime   = []byte("\033[90m")
-	colorWhite  = []byte("\033[37m")
-	colorPurple = []byte("\033[35m")
+// Theme holds the ANSI color sequences used to render each part of a log
+// line. Pass a custom Theme via Options.Theme to override the defaults.
+// A zero-value field falls back to the corresponding DefaultTheme color.
+type Theme struct {
+	Reset  []byte
+	Debug  []byte
+	Info   []byte
+	Warn   []byte
+	Error  []byte
+	Key    []byte
+	Value  []byte
+	Time   []byte
+	White  []byte
+	Purple []byte
+}
+
+// DefaultTheme is the color scheme used when Options.Theme is nil.
+var DefaultTheme = Theme{
+	Reset:  []byte("\033[0m"),
+	Debug:  []byte("\033[36m"),
+	Info:   []byte("\033[34m"),
+	Warn:   []byte("\033[33m"),
+	Error:  []byte("\033[31m"),
+	Key:    []byte("\033[32m"),
+	Value:  []byte("\033[38;5;216m"),
+	Time:   []byte("\033[90m"),
+	White:  []byte("\033[37m"),
+	Purple: []byte("\033[35m"),
+}
+
+var noColorTheme = Theme{}
+
+// ColorMode controls whether a PrettyTextHandler emits ANSI color codes.
+type ColorMode int
+
+const (
+	// ColorAuto emits colors only when the output looks like a terminal,
+	// honoring the NO_COLOR and FORCE_COLOR environment variables.
+	ColorAuto ColorMode = iota
+	// ColorAlways always emits colors, regardless of the output or
+	// environment.
+	ColorAlways
+	// ColorNever never emits colors.
+	ColorNever
+)
+
+// StackTraceMode controls when a PrettyTextHandler renders a stack trace
+// underneath a log line.
+type StackTraceMode int
+
+const (
+	// StackTracesNever never renders stack traces.
+	StackTracesNever StackTraceMode = iota
+	// StackTracesOnError renders a stack trace for any attribute whose
+	// value is an error carrying one (see PrettyFormatter).
+	StackTracesOnError
+	// StackTracesAlways behaves like StackTracesOnError, and additionally
+	// synthesizes a stack trace from the current call stack for
+	// slog.LevelError records (and above) that have no such attribute.
+	StackTracesAlways
 )
 
-var levelColors = map[slog.Level][]byte{
-	slog.LevelDebug: colorDebug,
-	slog.LevelInfo:  colorInfo,
-	slog.LevelWarn:  colorWarn,
-	slog.LevelError: colorError,
+// Options configures a PrettyTextHandler beyond the standard
+// slog.HandlerOptions.
+type Options struct {
+	slog.HandlerOptions
+
+	// Theme overrides the default ANSI colors. If nil, DefaultTheme is
+	// used (subject to Color). Only used by the default PrettyFormatter.
+	Theme *Theme
+
+	// TimeFormat overrides the time.Format layout used for the
+	// timestamp column. Defaults to "15:04:05.999". Only used by the
+	// default PrettyFormatter.
+	TimeFormat string
+
+	// Color controls whether ANSI colors are emitted. Defaults to
+	// ColorAuto. Only used by the default PrettyFormatter.
+	Color ColorMode
+
+	// StackTraces controls when a stack trace is rendered underneath a
+	// log line for error-typed attributes. Defaults to StackTracesNever.
+	// Only used by the default PrettyFormatter.
+	StackTraces StackTraceMode
+
+	// Formatter controls how a record is turned into bytes. If nil, a
+	// PrettyFormatter built from Theme/TimeFormat/Color is used. Pass a
+	// *JSONFormatter or *LogfmtFormatter for structured output; the
+	// handler still owns level filtering, buffering and group/attr
+	// plumbing, so switching formats doesn't require changing any
+	// logger setup code.
+	Formatter Formatter
+
+	// Vmodule, if non-empty, is parsed exactly like the argument to
+	// PrettyTextHandler.Vmodule and installed before the handler
+	// returns. New panics if spec is malformed; call Vmodule directly
+	// instead if you need to handle a bad spec without crashing.
+	Vmodule string
 }
 
-var levelNames = map[slog.Level]string{
-	slog.LevelDebug: "DEBUG",
-	slog.LevelInfo:  "INFO",
-	slog.LevelWarn:  "WARN",
-	slog.LevelError: "ERROR",
+// resolveTheme decides which Theme to use for out, applying Color,
+// NO_COLOR and FORCE_COLOR, then auto-detecting the terminal as a
+// fallback.
+func (o Options) resolveTheme(out io.Writer) Theme {
+	switch o.Color {
+	case ColorAlways:
+		return o.theme()
+	case ColorNever:
+		return noColorTheme
+	}
+
+	if os.Getenv("FORCE_COLOR") != "" {
+		return o.theme()
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return noColorTheme
+	}
+
+	if f, ok := out.(*os.File); ok && isTerminal(f) {
+		return o.theme()
+	}
+	return noColorTheme
+}
+
+// theme returns o.Theme with each zero-value field filled in from
+// DefaultTheme, per Theme's documented fallback, or DefaultTheme itself
+// when o.Theme is nil.
+func (o Options) theme() Theme {
+	if o.Theme == nil {
+		return DefaultTheme
+	}
+	t := *o.Theme
+	if t.Reset == nil {
+		t.Reset = DefaultTheme.Reset
+	}
+	if t.Debug == nil {
+		t.Debug = DefaultTheme.Debug
+	}
+	if t.Info == nil {
+		t.Info = DefaultTheme.Info
+	}
+	if t.Warn == nil {
+		t.Warn = DefaultTheme.Warn
+	}
+	if t.Error == nil {
+		t.Error = DefaultTheme.Error
+	}
+	if t.Key == nil {
+		t.Key = DefaultTheme.Key
+	}
+	if t.Value == nil {
+		t.Value = DefaultTheme.Value
+	}
+	if t.Time == nil {
+		t.Time = DefaultTheme.Time
+	}
+	if t.White == nil {
+		t.White = DefaultTheme.White
+	}
+	if t.Purple == nil {
+		t.Purple = DefaultTheme.Purple
+	}
+	return t
 }
 
 // PrettyTextHandler is a human-friendly slog handler that prints
 // colorized, aligned, low-allocation log lines.
 //
 // PrettyTextHandler supports slog groups, ReplaceAttr, AddSource, and
-// attribute propagation. It is safe for concurrent use.
+// attribute propagation. It is safe for concurrent use. Formatting runs
+// without holding any lock; a write lock is only taken when the write
+// isn't known-atomic (see isAtomicWrite).
 type PrettyTextHandler struct {
-	opts     slog.HandlerOptions
-	out      io.Writer
-	mu       sync.Mutex
-	group    string
-	preAttrs []slog.Attr
-	bufPool  *sync.Pool
+	opts      slog.HandlerOptions
+	out       io.Writer
+	writeMu   *sync.Mutex
+	group     string
+	preAttrs  []slog.Attr
+	bufPool   *sync.Pool
+	formatter Formatter
+	vmod      *atomic.Pointer[vmoduleMatcher]
+}
+
+// maxAtomicWriteSize is the largest buffer isAtomicWrite treats as safe
+// to write without a lock: POSIX only guarantees a write to a pipe is
+// atomic (never interleaved with a concurrent writer) up to PIPE_BUF,
+// which is 4096 bytes on Linux. os.Stdout is frequently a pipe in
+// production (container runtimes, `| tee`, etc.), so this applies even
+// though *os.File also covers regular files.
+const maxAtomicWriteSize = 4096
+
+// isAtomicWrite reports whether a single Write of n bytes to out can be
+// assumed to appear as one contiguous write to readers, so that
+// concurrent callers never interleave partial lines. This holds for
+// *os.File, but only up to maxAtomicWriteSize (see its doc comment);
+// anything larger, or any other io.Writer, is serialized with a lock.
+func isAtomicWrite(out io.Writer, n int) bool {
+	if n > maxAtomicWriteSize {
+		return false
+	}
+	_, ok := out.(*os.File)
+	return ok
 }
 
 // New creates a new PrettyTextHandler writing output to 'out'.
 //
-// The handler respects slog.HandlerOptions:
+// opts may be nil, in which case the defaults apply: level Info, no
+// source, no ReplaceAttr, auto-detected color and the "15:04:05.999"
+// time format.
+//
+// The handler respects slog.HandlerOptions (embedded in Options):
 // - Level: minimum log level
 // - AddSource: include file:line
 // - ReplaceAttr: transforms attributes
-func New(out io.Writer, opts *slog.HandlerOptions) *PrettyTextHandler {
+//
+// Color defaults to ColorAuto, which disables ANSI codes when out is
+// not a terminal, or when NO_COLOR is set, and forces them on when
+// FORCE_COLOR is set.
+func New(out io.Writer, opts *Options) *PrettyTextHandler {
 	h := &PrettyTextHandler{out: out}
+	var o Options
 	if opts != nil {
-		h.opts = *opts
+		o = *opts
 	}
+	h.opts = o.HandlerOptions
 	if h.opts.Level == nil {
 		h.opts.Level = slog.LevelInfo
 	}
+	h.writeMu = &sync.Mutex{}
 
 	h.bufPool = &sync.Pool{
 		New: func() interface{} {
@@ -90,19 +262,60 @@ func New(out io.Writer, opts *slog.HandlerOptions) *PrettyTextHandler {
 		},
 	}
 
+	h.formatter = o.Formatter
+	if h.formatter == nil {
+		timeFormat := o.TimeFormat
+		if timeFormat == "" {
+			timeFormat = defaultTimeFormat
+		}
+		h.formatter = &PrettyFormatter{
+			Opts:        h.opts,
+			Theme:       o.resolveTheme(out),
+			TimeFormat:  timeFormat,
+			StackTraces: o.StackTraces,
+		}
+	} else if setter, ok := h.formatter.(optsSetter); ok {
+		setter.setOpts(h.opts)
+	}
+
+	h.vmod = new(atomic.Pointer[vmoduleMatcher])
+	if o.Vmodule != "" {
+		if err := h.Vmodule(o.Vmodule); err != nil {
+			panic(err)
+		}
+	}
+
 	return h
 }
 
-// Enabled reports whether a log entry of the given level should be emitted.
+// Enabled reports whether a log entry of the given level should be
+// emitted. When Vmodule rules are active it also admits any level that a
+// rule could raise above the default, since the PC needed to know which
+// rule applies isn't available until Handle; Handle makes the final call.
 func (h *PrettyTextHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return level >= h.opts.Level.Level()
+	if level >= h.opts.Level.Level() {
+		return true
+	}
+	if m := h.vmod.Load(); m != nil && level >= m.minLevel {
+		return true
+	}
+	return false
 }
 
 // Handle formats and writes a slog.Record to the output.
-// It reuses an internal buffer pool for efficiency.
+// It reuses an internal buffer pool for efficiency. Formatting happens
+// without holding any lock; only the final Write is serialized, and only
+// when it isn't a known-atomic write (see isAtomicWrite).
 func (h *PrettyTextHandler) Handle(ctx context.Context, r slog.Record) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	if m := h.vmod.Load(); m != nil {
+		if lvl, ok := m.levelFor(r.PC); ok {
+			if r.Level < lvl {
+				return nil
+			}
+		} else if r.Level < h.opts.Level.Level() {
+			return nil
+		}
+	}
 
 	bufPtr := h.bufPool.Get().(*[]byte)
 	defer func() {
@@ -112,194 +325,17 @@ func (h *PrettyTextHandler) Handle(ctx context.Context, r slog.Record) error {
 	}()
 	buf := (*bufPtr)[:0]
 
-	buf = append(buf, colorTime...)
-	buf = r.Time.AppendFormat(buf, "15:04:05.999")
-	buf = append(buf, colorReset...)
-	buf = append(buf, ' ')
-
-	if h.opts.AddSource && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		if f.File != "" {
-			file := f.File
-			for i := len(file) - 1; i >= 0; i-- {
-				if file[i] == '/' || file[i] == '\\' {
-					file = file[i+1:]
-					break
-				}
-			}
-			buf = append(buf, colorTime...)
-			buf = append(buf, file...)
-			buf = append(buf, ':')
-			buf = strconv.AppendInt(buf, int64(f.Line), 10)
-			buf = append(buf, colorReset...)
-			buf = append(buf, ' ')
-		}
-	}
-
-	levelColor, ok := levelColors[r.Level]
-	if !ok {
-		levelColor = colorWhite
-	}
-	buf = append(buf, levelColor...)
-	levelStr := levelNames[r.Level]
-	buf = append(buf, levelStr...)
-	buf = append(buf, colorReset...)
-	padding := 5 - len(levelStr)
-	for i := 0; i < padding; i++ {
-		buf = append(buf, ' ')
-	}
-	buf = append(buf, ' ')
-
-	buf = append(buf, levelColor...)
-	buf = append(buf, r.Message...)
-	buf = append(buf, colorReset...)
-
-	var groups []string
-	if h.group != "" {
-		groups = strings.Split(h.group, ".")
-	}
-
-	appendAttr := func(a slog.Attr) {
-		if h.opts.ReplaceAttr != nil {
-			a = h.opts.ReplaceAttr(groups, a)
-			if a.Equal(slog.Attr{}) {
-				return
-			}
-		}
-
-		buf = append(buf, ' ')
-		buf = append(buf, colorKey...)
-		if h.group != "" {
-			buf = append(buf, h.group...)
-			buf = append(buf, '.')
-			buf = append(buf, a.Key...)
-		} else {
-			buf = append(buf, a.Key...)
-		}
-		buf = append(buf, colorReset...)
-		buf = append(buf, '=')
-		buf = append(buf, colorValue...)
-		buf = appendValue(buf, a.Value)
-
-		buf = append(buf, colorReset...)
-	}
+	buf = h.formatter.AppendRecord(buf, r, h.preAttrs, h.group)
+	buf = append(buf, '\n')
 
-	for _, a := range h.preAttrs {
-		appendAttr(a)
+	if !isAtomicWrite(h.out, len(buf)) {
+		h.writeMu.Lock()
+		defer h.writeMu.Unlock()
 	}
-	r.Attrs(func(a slog.Attr) bool {
-		appendAttr(a)
-		return true
-	})
-
-	buf = append(buf, '\n')
 	_, err := h.out.Write(buf)
 	return err
 }
 
-func appendValue(buf []byte, v slog.Value) []byte {
-	switch v.Kind() {
-	case slog.KindString:
-		return append(buf, v.String()...)
-	case slog.KindBool:
-		return strconv.AppendBool(buf, v.Bool())
-	case slog.KindInt64:
-		return strconv.AppendInt(buf, v.Int64(), 10)
-	case slog.KindUint64:
-		return strconv.AppendUint(buf, v.Uint64(), 10)
-	case slog.KindFloat64:
-		return strconv.AppendFloat(buf, v.Float64(), 'f', -1, 64)
-	case slog.KindDuration:
-		return appendDuration(buf, v.Duration())
-	case slog.KindTime:
-		return v.Time().AppendFormat(buf, time.RFC3339Nano)
-	case slog.KindGroup:
-		attrs := v.Group()
-		buf = append(buf, colorReset...)
-		buf = append(buf, colorPurple...)
-		buf = append(buf, '(')
-		for i, attr := range attrs {
-			if i > 0 {
-				buf = append(buf, ' ')
-			}
-			buf = append(buf, colorReset...)
-			buf = append(buf, colorKey...)
-			buf = append(buf, attr.Key...)
-			buf = append(buf, colorReset...)
-			buf = append(buf, '=')
-			buf = append(buf, colorValue...)
-			buf = appendValue(buf, attr.Value)
-			buf = append(buf, colorReset...)
-		}
-		buf = append(buf, colorPurple...)
-		buf = append(buf, ')')
-		buf = append(buf, colorReset...)
-		return buf
-	case slog.KindAny:
-		if bs, ok := byteSlice(v.Any()); ok {
-			return append(buf, bs...)
-		}
-		return fmt.Append(buf, v.Any())
-	default:
-		return append(buf, v.String()...)
-	}
-}
-
-func byteSlice(a any) ([]byte, bool) {
-	if bs, ok := a.([]byte); ok {
-		return bs, true
-	}
-
-	t := reflect.TypeOf(a)
-	if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
-		return reflect.ValueOf(a).Bytes(), true
-	}
-	return nil, false
-}
-
-func appendDuration(buf []byte, d time.Duration) []byte {
-	if d == 0 {
-		return append(buf, "0s"...)
-	}
-
-	neg := d < 0
-	if neg {
-		d = -d
-	}
-
-	u := uint64(d)
-
-	if u < uint64(time.Second) {
-		buf = strconv.AppendFloat(buf, float64(u)/1000000, 'f', -1, 64)
-		buf = append(buf, 'm')
-		buf = append(buf, 's')
-	} else {
-		secs := u / uint64(time.Second)
-		nsecs := u % uint64(time.Second)
-
-		buf = strconv.AppendUint(buf, secs, 10)
-
-		if nsecs > 0 {
-			buf = append(buf, '.')
-			var nsBuf [9]byte
-			ns := strconv.AppendUint(nsBuf[:0], nsecs, 10)
-			for i := 0; i < 9-len(ns); i++ {
-				buf = append(buf, '0')
-			}
-			buf = append(buf, ns...)
-		}
-
-		buf = append(buf, 's')
-	}
-
-	if neg {
-		buf = append([]byte{'-'}, buf...)
-	}
-
-	return buf
-}
-
 // WithAttrs returns a new handler with additional pre‑attached attributes.
 // The attributes will be written for every log entry.
 func (h *PrettyTextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
@@ -311,12 +347,14 @@ func (h *PrettyTextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	newPreAttrs = append(newPreAttrs, attrs...)
 
 	return &PrettyTextHandler{
-		opts:     h.opts,
-		out:      h.out,
-		mu:       sync.Mutex{},
-		group:    h.group,
-		preAttrs: newPreAttrs,
-		bufPool:  h.bufPool,
+		opts:      h.opts,
+		out:       h.out,
+		writeMu:   h.writeMu,
+		group:     h.group,
+		preAttrs:  newPreAttrs,
+		bufPool:   h.bufPool,
+		formatter: h.formatter,
+		vmod:      h.vmod,
 	}
 }
 
@@ -333,11 +371,13 @@ func (h *PrettyTextHandler) WithGroup(name string) slog.Handler {
 		newGroup = name
 	}
 	return &PrettyTextHandler{
-		opts:     h.opts,
-		out:      h.out,
-		mu:       sync.Mutex{},
-		group:    newGroup,
-		preAttrs: h.preAttrs,
-		bufPool:  h.bufPool,
+		opts:      h.opts,
+		out:       h.out,
+		writeMu:   h.writeMu,
+		group:     newGroup,
+		preAttrs:  h.preAttrs,
+		bufPool:   h.bufPool,
+		formatter: h.formatter,
+		vmod:      h.vmod,
 	}
 }
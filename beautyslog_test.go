@@ -1,12 +1,16 @@
 package beautyslog
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"os"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -53,9 +57,11 @@ func BenchmarkSlogTextHandlerWithSource(b *testing.B) {
 }
 
 func BenchmarkPrettyTextHandlerWithSource(b *testing.B) {
-	handler := New(io.Discard, &slog.HandlerOptions{
-		AddSource: true,
-		Level:     slog.LevelInfo,
+	handler := New(io.Discard, &Options{
+		HandlerOptions: slog.HandlerOptions{
+			AddSource: true,
+			Level:     slog.LevelInfo,
+		},
 	})
 	record := createTestRecord()
 
@@ -87,9 +93,11 @@ func BenchmarkSlogTextHandlerWithoutSource(b *testing.B) {
 }
 
 func BenchmarkPrettyTextHandlerWithoutSource(b *testing.B) {
-	handler := New(io.Discard, &slog.HandlerOptions{
-		AddSource: false,
-		Level:     slog.LevelInfo,
+	handler := New(io.Discard, &Options{
+		HandlerOptions: slog.HandlerOptions{
+			AddSource: false,
+			Level:     slog.LevelInfo,
+		},
 	})
 	record := createTestRecord()
 
@@ -103,16 +111,49 @@ func BenchmarkPrettyTextHandlerWithoutSource(b *testing.B) {
 	}
 }
 
-func TestPrint(_ *testing.T) {
-	prettyHandler := New(os.Stdout, &slog.HandlerOptions{
-		Level:     slog.LevelDebug,
-		AddSource: true,
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			if a.Key == "password" || a.Key == "token" {
-				return slog.String(a.Key, "*****")
+func BenchmarkPrettyTextHandlerParallel(b *testing.B) {
+	// An *os.File is required here, not io.Discard: isAtomicWrite only
+	// recognizes *os.File, so a non-file writer falls back to writeMu
+	// and never exercises the lock-free path this benchmark measures.
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+
+	handler := New(devNull, &Options{
+		HandlerOptions: slog.HandlerOptions{
+			AddSource: true,
+			Level:     slog.LevelInfo,
+		},
+	})
+	record := createTestRecord()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := handler.Handle(context.TODO(), record); err != nil {
+				b.Fatal(err)
 			}
-			return a
+		}
+	})
+}
+
+func TestPrint(_ *testing.T) {
+	prettyHandler := New(os.Stdout, &Options{
+		HandlerOptions: slog.HandlerOptions{
+			Level:     slog.LevelDebug,
+			AddSource: true,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == "password" || a.Key == "token" {
+					return slog.String(a.Key, "*****")
+				}
+				return a
+			},
 		},
+		Color: ColorAlways,
 	})
 
 	logger := slog.New(prettyHandler)
@@ -129,4 +170,291 @@ func TestPrint(_ *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 	logger.Info("duration", slog.Duration("ddd", time.Hour), slog.Duration("ms", time.Microsecond))
 	logger.Info("without slog types", "123k", 123, "dur", time.Hour, "b", true, "er", errors.New("dss"))
+	logger.Log(context.Background(), slog.LevelDebug-4, "below debug")
+	logger.Log(context.Background(), slog.LevelInfo+2, "between info and warn")
+	logger.Log(context.Background(), slog.LevelWarn+1, "just above warn")
+}
+
+func TestResolveThemeEnv(t *testing.T) {
+	var buf bytes.Buffer // not a terminal, so ColorAuto alone would pick noColorTheme
+
+	t.Run("FORCE_COLOR forces color on a non-terminal writer", func(t *testing.T) {
+		t.Setenv("FORCE_COLOR", "1")
+		if got := (Options{}).resolveTheme(&buf); !reflect.DeepEqual(got, DefaultTheme) {
+			t.Errorf("resolveTheme() = %+v, want DefaultTheme", got)
+		}
+	})
+
+	t.Run("NO_COLOR disables color", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		if got := (Options{}).resolveTheme(&buf); !reflect.DeepEqual(got, noColorTheme) {
+			t.Errorf("resolveTheme() = %+v, want noColorTheme", got)
+		}
+	})
+
+	t.Run("FORCE_COLOR takes priority over NO_COLOR", func(t *testing.T) {
+		t.Setenv("FORCE_COLOR", "1")
+		t.Setenv("NO_COLOR", "1")
+		if got := (Options{}).resolveTheme(&buf); !reflect.DeepEqual(got, DefaultTheme) {
+			t.Errorf("resolveTheme() = %+v, want DefaultTheme", got)
+		}
+	})
+
+	t.Run("sparse custom Theme overrides only the fields it sets", func(t *testing.T) {
+		t.Setenv("FORCE_COLOR", "1")
+		custom := Theme{Info: []byte("\033[99m")}
+		got := (Options{Theme: &custom}).resolveTheme(&buf)
+
+		want := DefaultTheme
+		want.Info = []byte("\033[99m")
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("resolveTheme() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestCustomThemeRendering(t *testing.T) {
+	var buf bytes.Buffer
+	// Only Info is set; every other field (notably Reset) must fall back
+	// to DefaultTheme per Theme's doc comment, or the custom color would
+	// bleed into everything written after it.
+	custom := Theme{Info: []byte("\033[95m")}
+	logger := slog.New(New(&buf, &Options{Color: ColorAlways, Theme: &custom}))
+
+	logger.Info("hello", "k", "v")
+	got := buf.String()
+	if !strings.Contains(got, "\033[95m") {
+		t.Errorf("expected custom theme color in output, got %q", got)
+	}
+	if strings.Contains(got, string(DefaultTheme.Info)) {
+		t.Errorf("expected custom theme to replace DefaultTheme.Info, got %q", got)
+	}
+	if !strings.Contains(got, string(DefaultTheme.Reset)) {
+		t.Errorf("expected DefaultTheme.Reset to be used as a fallback, got %q", got)
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	cases := map[slog.Level]string{
+		slog.LevelDebug - 4: "DEBUG-4",
+		slog.LevelDebug:     "DEBUG",
+		slog.LevelInfo:      "INFO",
+		slog.LevelInfo + 2:  "INFO+2",
+		slog.LevelWarn + 1:  "WARN+1",
+		slog.LevelError:     "ERROR",
+		slog.LevelError + 4: "ERROR+4",
+	}
+	for level, want := range cases {
+		if got := levelString(level); got != want {
+			t.Errorf("levelString(%v) = %q, want %q", level, got, want)
+		}
+	}
+}
+
+func TestReplaceAttrRemapsLevelColor(t *testing.T) {
+	const fatalLevel = slog.LevelError + 4
+	magenta := []byte("\033[95m")
+
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, &Options{
+		Color: ColorAlways,
+		HandlerOptions: slog.HandlerOptions{
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey && a.Value.Any().(slog.Level) == fatalLevel {
+					return slog.Any(slog.LevelKey, LevelColor{Text: "FATAL", Color: magenta})
+				}
+				return a
+			},
+		},
+	}))
+
+	logger.Log(context.Background(), fatalLevel, "everything is on fire")
+	got := buf.String()
+	if !strings.Contains(got, "FATAL") {
+		t.Errorf("expected remapped level text FATAL, got %q", got)
+	}
+	if !strings.Contains(got, string(magenta)) {
+		t.Errorf("expected remapped level color, got %q", got)
+	}
+	if strings.Contains(got, string(DefaultTheme.Error)) {
+		t.Errorf("expected remapped level to not use the stock ERROR color, got %q", got)
+	}
+}
+
+func TestJSONAndLogfmtFormatters(t *testing.T) {
+	var jsonBuf, logfmtBuf bytes.Buffer
+
+	jsonLogger := slog.New(New(&jsonBuf, &Options{Formatter: &JSONFormatter{}}))
+	jsonLogger.Info("start request", slog.String("request_id", "req-1"))
+	if got := jsonBuf.String(); !strings.Contains(got, `"msg":"start request"`) || !strings.Contains(got, `"request_id":"req-1"`) {
+		t.Errorf("JSONFormatter output missing expected fields: %s", got)
+	}
+
+	logfmtLogger := slog.New(New(&logfmtBuf, &Options{Formatter: &LogfmtFormatter{}}))
+	logfmtLogger.Info("start request", slog.String("request_id", "req-1"))
+	if got := logfmtBuf.String(); !strings.Contains(got, `msg=`) || !strings.Contains(got, `request_id=req-1`) {
+		t.Errorf("LogfmtFormatter output missing expected fields: %s", got)
+	}
+}
+
+func TestJSONFormatterEscapesInvalidBytes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, &Options{Formatter: &JSONFormatter{}}))
+	logger.Info("echo\x00\x7f\xffinput", slog.String("field", "a\x00b"))
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSONFormatter produced invalid JSON: %v (%s)", err, buf.String())
+	}
+}
+
+func TestJSONFormatterError(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, &Options{Formatter: &JSONFormatter{}}))
+	logger.Error("failed", "err", errors.New("boom: disk full"))
+	if got := buf.String(); !strings.Contains(got, `"err":"boom: disk full"`) {
+		t.Errorf("JSONFormatter dropped error message: %s", got)
+	}
+}
+
+func TestFormatterInheritsHandlerOptions(t *testing.T) {
+	redact := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == "password" {
+			return slog.String(a.Key, "*****")
+		}
+		return a
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, &Options{
+		Formatter: &JSONFormatter{},
+		HandlerOptions: slog.HandlerOptions{
+			AddSource:   true,
+			ReplaceAttr: redact,
+		},
+	}))
+	logger.Info("login", slog.String("password", "hunter2"))
+
+	got := buf.String()
+	if strings.Contains(got, "hunter2") {
+		t.Errorf("JSONFormatter should have inherited ReplaceAttr from HandlerOptions, got %s", got)
+	}
+	if !strings.Contains(got, `"password":"*****"`) {
+		t.Errorf("expected redacted password, got %s", got)
+	}
+	if !strings.Contains(got, `"source":`) {
+		t.Errorf("JSONFormatter should have inherited AddSource from HandlerOptions, got %s", got)
+	}
+}
+
+func TestIsAtomicWrite(t *testing.T) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if !isAtomicWrite(f, maxAtomicWriteSize) {
+		t.Error("expected a write at the PIPE_BUF-sized threshold to an *os.File to be lock-free")
+	}
+	if isAtomicWrite(f, maxAtomicWriteSize+1) {
+		t.Error("expected a write above the PIPE_BUF-sized threshold to require a lock")
+	}
+	if isAtomicWrite(&bytes.Buffer{}, 1) {
+		t.Error("expected a non-*os.File writer to always require a lock")
+	}
+}
+
+func TestVmodule(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(&buf, &Options{
+		HandlerOptions: slog.HandlerOptions{Level: slog.LevelWarn},
+		Formatter:      &LogfmtFormatter{},
+	})
+	logger := slog.New(h)
+
+	logger.Debug("quiet by default")
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing logged before Vmodule, got %q", buf.String())
+	}
+
+	if err := h.Vmodule("beautyslog_test.go=debug"); err != nil {
+		t.Fatalf("Vmodule returned error: %v", err)
+	}
+	logger.Debug("loud after vmodule")
+	if got := buf.String(); !strings.Contains(got, "loud after vmodule") {
+		t.Errorf("expected vmodule override to admit debug line, got %q", got)
+	}
+
+	buf.Reset()
+	if err := h.Vmodule("nomatch/*=debug"); err != nil {
+		t.Fatalf("Vmodule returned error: %v", err)
+	}
+	logger.Debug("quiet again")
+	if buf.Len() != 0 {
+		t.Errorf("expected non-matching rule to fall back to Level, got %q", buf.String())
+	}
+
+	if err := h.Vmodule("["); err == nil {
+		t.Error("expected error for malformed vmodule spec")
+	}
+}
+
+// tracedError mimics the github.com/pkg/errors convention: a StackTrace
+// method returning a uintptr-based slice, plus Unwrap for wrapping.
+type tracedError struct {
+	msg string
+	pcs []uintptr
+}
+
+func (e *tracedError) Error() string { return e.msg }
+
+func (e *tracedError) StackTrace() []uintptr { return e.pcs }
+
+type wrappingError struct {
+	msg string
+	err error
+}
+
+func (e *wrappingError) Error() string { return e.msg }
+func (e *wrappingError) Unwrap() error { return e.err }
+
+func TestStackTraceRendering(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	traced := &tracedError{msg: "boom", pcs: pcs[:]}
+
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, &Options{Color: ColorAlways, StackTraces: StackTracesOnError}))
+	logger.Error("request failed", slog.Any("err", traced))
+	if got := buf.String(); !strings.Contains(got, "boom") || !strings.Contains(got, "beautyslog_test.go") {
+		t.Errorf("expected inline error and stack frame, got %q", got)
+	}
+
+	buf.Reset()
+	wrapped := &wrappingError{msg: "outer", err: traced}
+	logger.Error("wrapped failure", slog.Any("err", wrapped))
+	if got := buf.String(); !strings.Contains(got, "beautyslog_test.go") {
+		t.Errorf("expected stack frame from unwrapped error, got %q", got)
+	}
+
+	buf.Reset()
+	logger.Error("no stack here", slog.String("reason", "plain"))
+	if got := buf.String(); strings.Count(got, "\n") > 1 {
+		t.Errorf("StackTracesOnError must not synthesize a trace without a stack-bearing error, got %q", got)
+	}
+
+	buf.Reset()
+	alwaysLogger := slog.New(New(&buf, &Options{Color: ColorAlways, StackTraces: StackTracesAlways}))
+	alwaysLogger.Error("synthesized", slog.String("reason", "plain"))
+	got := buf.String()
+	if strings.Count(got, "\n") <= 1 {
+		t.Errorf("StackTracesAlways should synthesize a trace for errors lacking one, got %q", got)
+	}
+	if strings.Contains(got, "beautyslog.(*PrettyTextHandler).Handle") || strings.Contains(got, "log/slog") {
+		t.Errorf("synthesized trace should start at the slog call site, not inside the library, got %q", got)
+	}
+	if top := got[strings.Index(got, "\n")+1:]; !strings.Contains(top[:strings.Index(top, "\n")], "TestStackTraceRendering") {
+		t.Errorf("synthesized trace's top frame should be the caller of Error, got %q", got)
+	}
 }
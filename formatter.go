@@ -0,0 +1,140 @@
+package beautyslog
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+// Formatter renders a single slog.Record into buf and returns the
+// extended slice. preAttrs are the attributes accumulated through prior
+// WithAttrs calls, and group is the dot-joined name accumulated through
+// prior WithGroup calls (empty if none). Implementations must not retain
+// buf, or any slice derived from it, past the call.
+//
+// PrettyTextHandler owns buffering, level filtering and the
+// WithAttrs/WithGroup plumbing; a Formatter only controls byte
+// emission, so swapping formats never requires changing logger setup
+// code.
+type Formatter interface {
+	AppendRecord(buf []byte, r slog.Record, preAttrs []slog.Attr, group string) []byte
+}
+
+// optsSetter lets New propagate the resolved slog.HandlerOptions into a
+// Formatter supplied via Options.Formatter. Without it, a caller's
+// ReplaceAttr and AddSource would only reach the default PrettyFormatter
+// New builds itself, and would be silently ignored by any JSONFormatter
+// or LogfmtFormatter the caller constructs, since those start out with
+// a zero-value Opts.
+type optsSetter interface {
+	setOpts(slog.HandlerOptions)
+}
+
+// levelBucket is a named level that anchors the rendering of nearby
+// custom levels, e.g. slog.LevelInfo+2 renders as "INFO+2".
+type levelBucket struct {
+	level slog.Level
+	name  string
+}
+
+// levelBuckets must stay sorted ascending by level.
+var levelBuckets = []levelBucket{
+	{slog.LevelDebug, "DEBUG"},
+	{slog.LevelInfo, "INFO"},
+	{slog.LevelWarn, "WARN"},
+	{slog.LevelError, "ERROR"},
+}
+
+// bucketFor returns the nearest known level at or below level, clamped to
+// the lowest bucket (DEBUG) when level falls below it.
+func bucketFor(level slog.Level) levelBucket {
+	bucket := levelBuckets[0]
+	for _, b := range levelBuckets {
+		if level < b.level {
+			break
+		}
+		bucket = b
+	}
+	return bucket
+}
+
+// levelString renders level as its bucket name plus a signed delta when
+// it doesn't land exactly on a known level, e.g. "INFO+2", "DEBUG-4".
+func levelString(level slog.Level) string {
+	bucket := bucketFor(level)
+	delta := int(level - bucket.level)
+	if delta == 0 {
+		return bucket.name
+	}
+	if delta > 0 {
+		return bucket.name + "+" + strconv.Itoa(delta)
+	}
+	return bucket.name + strconv.Itoa(delta)
+}
+
+// levelAttrString renders a ReplaceAttr-transformed slog.LevelKey value
+// as display text. String values are used verbatim so callers can remap
+// custom levels (e.g. a TRACE or FATAL tier) to their own label.
+func levelAttrString(v slog.Value) string {
+	if v.Kind() == slog.KindString {
+		return v.String()
+	}
+	return fmt.Sprint(v.Any())
+}
+
+// LevelColor lets a ReplaceAttr hook for slog.LevelKey remap a custom
+// level to its own color, not just its own text. Return one in place of
+// a plain string or slog.Level to control the ANSI color
+// PrettyFormatter uses for a level (e.g. a TRACE or FATAL tier) that
+// shouldn't inherit the color of whatever stock bucket its numeric
+// value happens to fall into.
+type LevelColor struct {
+	Text  string
+	Color []byte
+}
+
+// resolveLevel applies replaceAttr (if non-nil) to the slog.LevelKey
+// attribute and returns the text a formatter should render, plus a
+// color override. color is nil unless replaceAttr returned a
+// LevelColor, in which case callers that render ANSI colors should use
+// it verbatim instead of picking a color from level's bucket.
+func resolveLevel(replaceAttr func([]string, slog.Attr) slog.Attr, level slog.Level) (text string, color []byte) {
+	if replaceAttr != nil {
+		if a := replaceAttr(nil, slog.Any(slog.LevelKey, level)); !a.Equal(slog.Attr{}) {
+			if lc, ok := a.Value.Any().(LevelColor); ok {
+				return lc.Text, lc.Color
+			}
+			return levelAttrString(a.Value), nil
+		}
+	}
+	return levelString(level), nil
+}
+
+// resolveLevelText is resolveLevel without the color, for formatters
+// that don't render ANSI colors.
+func resolveLevelText(replaceAttr func([]string, slog.Attr) slog.Attr, level slog.Level) string {
+	text, _ := resolveLevel(replaceAttr, level)
+	return text
+}
+
+// resolveSource resolves pc to a "file:line" pair, trimming file to its
+// base name. ok is false if pc is 0 or unresolvable.
+func resolveSource(pc uintptr) (file string, line int, ok bool) {
+	if pc == 0 {
+		return "", 0, false
+	}
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	if f.File == "" {
+		return "", 0, false
+	}
+	file = f.File
+	for i := len(file) - 1; i >= 0; i-- {
+		if file[i] == '/' || file[i] == '\\' {
+			file = file[i+1:]
+			break
+		}
+	}
+	return file, f.Line, true
+}
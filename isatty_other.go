@@ -0,0 +1,14 @@
+//go:build !windows && !linux
+
+package beautyslog
+
+import "os"
+
+// isTerminal reports whether f is connected to a terminal.
+//
+// Terminal detection on this platform is not implemented (the ioctl
+// numbers used by isatty_linux.go are Linux-specific); callers can still
+// force colors on with Options.Color or FORCE_COLOR.
+func isTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,11 @@
+package beautyslog
+
+import "os"
+
+// isTerminal reports whether f is connected to a terminal.
+//
+// Windows terminal detection is not implemented; callers can still force
+// colors on with Options.Color or FORCE_COLOR.
+func isTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,151 @@
+package beautyslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONFormatter renders each record as a single-line JSON object, for
+// structured production logging. Pass it via Options.Formatter to swap
+// a PrettyTextHandler from local terminal output to JSON without
+// touching any logger setup code.
+type JSONFormatter struct {
+	Opts slog.HandlerOptions
+}
+
+// setOpts implements optsSetter.
+func (f *JSONFormatter) setOpts(o slog.HandlerOptions) { f.Opts = o }
+
+// AppendRecord implements Formatter.
+func (f *JSONFormatter) AppendRecord(buf []byte, r slog.Record, preAttrs []slog.Attr, group string) []byte {
+	buf = append(buf, '{')
+
+	buf = append(buf, `"time":`...)
+	buf = appendJSONString(buf, r.Time.Format(time.RFC3339Nano))
+
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, resolveLevelText(f.Opts.ReplaceAttr, r.Level))
+
+	if f.Opts.AddSource {
+		if file, line, ok := resolveSource(r.PC); ok {
+			buf = append(buf, `,"source":`...)
+			buf = appendJSONString(buf, file+":"+strconv.Itoa(line))
+		}
+	}
+
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, r.Message)
+
+	var groups []string
+	if group != "" {
+		groups = strings.Split(group, ".")
+	}
+
+	appendAttr := func(a slog.Attr) {
+		if f.Opts.ReplaceAttr != nil {
+			a = f.Opts.ReplaceAttr(groups, a)
+			if a.Equal(slog.Attr{}) {
+				return
+			}
+		}
+
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, qualifiedKey(group, a.Key))
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, a.Value)
+	}
+
+	for _, a := range preAttrs {
+		appendAttr(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(a)
+		return true
+	})
+
+	buf = append(buf, '}')
+	return buf
+}
+
+// qualifiedKey prefixes key with group (from WithGroup) using the same
+// dot notation the PrettyFormatter uses.
+func qualifiedKey(group, key string) string {
+	if group == "" {
+		return key
+	}
+	return group + "." + key
+}
+
+func appendJSONValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendJSONString(buf, v.String())
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		return strconv.AppendFloat(buf, v.Float64(), 'f', -1, 64)
+	case slog.KindDuration:
+		return appendJSONString(buf, v.Duration().String())
+	case slog.KindTime:
+		return appendJSONString(buf, v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		return appendJSONGroup(buf, v.Group())
+	case slog.KindAny:
+		if bs, ok := byteSlice(v.Any()); ok {
+			return appendJSONString(buf, string(bs))
+		}
+		// Mirror log/slog's JSONHandler: most error values have no
+		// exported fields and marshal to "{}", silently dropping the
+		// message, so prefer Error() unless the value customizes its
+		// own JSON encoding.
+		if err, ok := v.Any().(error); ok {
+			if _, ok := v.Any().(json.Marshaler); !ok {
+				return appendJSONString(buf, err.Error())
+			}
+		}
+		b, err := json.Marshal(v.Any())
+		if err != nil {
+			return appendJSONString(buf, fmt.Sprint(v.Any()))
+		}
+		return append(buf, b...)
+	default:
+		return appendJSONString(buf, v.String())
+	}
+}
+
+func appendJSONGroup(buf []byte, attrs []slog.Attr) []byte {
+	buf = append(buf, '{')
+	for i, a := range attrs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, a.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, a.Value)
+	}
+	buf = append(buf, '}')
+	return buf
+}
+
+// appendJSONString appends s to buf as a JSON string literal, using
+// encoding/json's escaping rules rather than strconv.AppendQuote's
+// Go-syntax quoting: the latter emits \xNN/\uNNNN-for-single-byte
+// escapes for invalid UTF-8 and bytes like 0x7f, which are not legal
+// JSON escape sequences and make the output fail to parse.
+func appendJSONString(buf []byte, s string) []byte {
+	b, err := json.Marshal(s)
+	if err != nil {
+		// json.Marshal never fails for a plain string; fall back
+		// defensively rather than drop the value.
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, b...)
+}
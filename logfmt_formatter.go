@@ -0,0 +1,127 @@
+package beautyslog
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtFormatter renders each record as space-separated key=value
+// pairs in the logfmt style (time=... level=INFO msg="..." key=value).
+// Pass it via Options.Formatter to swap a PrettyTextHandler from local
+// terminal output to logfmt without touching any logger setup code.
+type LogfmtFormatter struct {
+	Opts slog.HandlerOptions
+}
+
+// setOpts implements optsSetter.
+func (f *LogfmtFormatter) setOpts(o slog.HandlerOptions) { f.Opts = o }
+
+// AppendRecord implements Formatter.
+func (f *LogfmtFormatter) AppendRecord(buf []byte, r slog.Record, preAttrs []slog.Attr, group string) []byte {
+	buf = append(buf, "time="...)
+	buf = appendLogfmtString(buf, r.Time.Format(time.RFC3339Nano))
+
+	buf = append(buf, " level="...)
+	buf = appendLogfmtString(buf, resolveLevelText(f.Opts.ReplaceAttr, r.Level))
+
+	if f.Opts.AddSource {
+		if file, line, ok := resolveSource(r.PC); ok {
+			buf = append(buf, " source="...)
+			buf = appendLogfmtString(buf, file+":"+strconv.Itoa(line))
+		}
+	}
+
+	buf = append(buf, " msg="...)
+	buf = appendLogfmtString(buf, r.Message)
+
+	var groups []string
+	if group != "" {
+		groups = strings.Split(group, ".")
+	}
+
+	appendAttr := func(a slog.Attr) {
+		if f.Opts.ReplaceAttr != nil {
+			a = f.Opts.ReplaceAttr(groups, a)
+			if a.Equal(slog.Attr{}) {
+				return
+			}
+		}
+		buf = appendLogfmtAttr(buf, group, a)
+	}
+
+	for _, a := range preAttrs {
+		appendAttr(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(a)
+		return true
+	})
+
+	return buf
+}
+
+// appendLogfmtAttr renders a, flattening nested groups into dotted keys
+// (e.g. group.a=b) the same way PrettyTextHandler's WithGroup does.
+func appendLogfmtAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	key := qualifiedKey(prefix, a.Key)
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			buf = appendLogfmtAttr(buf, key, ga)
+		}
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	buf = appendLogfmtString(buf, key)
+	buf = append(buf, '=')
+	buf = appendLogfmtValue(buf, a.Value)
+	return buf
+}
+
+func appendLogfmtValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendLogfmtString(buf, v.String())
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		return strconv.AppendFloat(buf, v.Float64(), 'f', -1, 64)
+	case slog.KindDuration:
+		return appendLogfmtString(buf, v.Duration().String())
+	case slog.KindTime:
+		return appendLogfmtString(buf, v.Time().Format(time.RFC3339Nano))
+	case slog.KindAny:
+		if bs, ok := byteSlice(v.Any()); ok {
+			return appendLogfmtString(buf, string(bs))
+		}
+		return appendLogfmtString(buf, fmt.Sprint(v.Any()))
+	default:
+		return appendLogfmtString(buf, v.String())
+	}
+}
+
+func appendLogfmtString(buf []byte, s string) []byte {
+	if needsLogfmtQuote(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needsLogfmtQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '"' || r == '=' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package beautyslog
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrettyFormatter renders colorized, aligned, human-friendly terminal
+// lines. It is the Formatter PrettyTextHandler uses by default; build
+// one directly (or via Options) to reuse it outside of New, e.g. when
+// composing a custom handler.
+type PrettyFormatter struct {
+	Opts slog.HandlerOptions
+
+	// Theme controls the ANSI colors. The zero value emits no color.
+	Theme Theme
+
+	// TimeFormat is the time.Format layout for the timestamp column.
+	// Defaults to "15:04:05.999" when empty.
+	TimeFormat string
+
+	// StackTraces controls when a stack trace is rendered underneath the
+	// line. Defaults to StackTracesNever.
+	StackTraces StackTraceMode
+}
+
+// setOpts implements optsSetter.
+func (f *PrettyFormatter) setOpts(o slog.HandlerOptions) { f.Opts = o }
+
+// AppendRecord implements Formatter.
+func (f *PrettyFormatter) AppendRecord(buf []byte, r slog.Record, preAttrs []slog.Attr, group string) []byte {
+	timeFormat := f.TimeFormat
+	if timeFormat == "" {
+		timeFormat = defaultTimeFormat
+	}
+
+	buf = append(buf, f.Theme.Time...)
+	buf = r.Time.AppendFormat(buf, timeFormat)
+	buf = append(buf, f.Theme.Reset...)
+	buf = append(buf, ' ')
+
+	if f.Opts.AddSource {
+		if file, line, ok := resolveSource(r.PC); ok {
+			buf = append(buf, f.Theme.Time...)
+			buf = append(buf, file...)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(line), 10)
+			buf = append(buf, f.Theme.Reset...)
+			buf = append(buf, ' ')
+		}
+	}
+
+	levelStr, colorOverride := resolveLevel(f.Opts.ReplaceAttr, r.Level)
+	levelColor := f.levelColor(colorOverride, r.Level)
+	buf = append(buf, levelColor...)
+	buf = append(buf, levelStr...)
+	buf = append(buf, f.Theme.Reset...)
+	padding := 5 - len(levelStr)
+	for i := 0; i < padding; i++ {
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, ' ')
+
+	buf = append(buf, levelColor...)
+	buf = append(buf, r.Message...)
+	buf = append(buf, f.Theme.Reset...)
+
+	var groups []string
+	if group != "" {
+		groups = strings.Split(group, ".")
+	}
+
+	var traces [][]uintptr
+
+	appendAttr := func(a slog.Attr) {
+		if f.Opts.ReplaceAttr != nil {
+			a = f.Opts.ReplaceAttr(groups, a)
+			if a.Equal(slog.Attr{}) {
+				return
+			}
+		}
+
+		buf = append(buf, ' ')
+		buf = append(buf, f.Theme.Key...)
+		if group != "" {
+			buf = append(buf, group...)
+			buf = append(buf, '.')
+			buf = append(buf, a.Key...)
+		} else {
+			buf = append(buf, a.Key...)
+		}
+		buf = append(buf, f.Theme.Reset...)
+		buf = append(buf, '=')
+		buf = append(buf, f.Theme.Value...)
+		buf = appendValue(buf, a.Value, f.Theme)
+
+		buf = append(buf, f.Theme.Reset...)
+
+		if f.StackTraces != StackTracesNever {
+			if err, ok := a.Value.Any().(error); ok {
+				if pcs, ok := stackTrace(err); ok {
+					traces = append(traces, pcs)
+				}
+			}
+		}
+	}
+
+	for _, a := range preAttrs {
+		appendAttr(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(a)
+		return true
+	})
+
+	for _, pcs := range traces {
+		buf = appendStackTrace(buf, pcs, f.Theme)
+	}
+	if f.StackTraces == StackTracesAlways && len(traces) == 0 && r.Level >= slog.LevelError {
+		buf = appendStackTrace(buf, callerStack(), f.Theme)
+	}
+
+	return buf
+}
+
+// levelColor returns override if ReplaceAttr supplied one via
+// LevelColor, otherwise the theme color for the bucket level falls
+// into.
+func (f *PrettyFormatter) levelColor(override []byte, level slog.Level) []byte {
+	if override != nil {
+		return override
+	}
+	switch bucketFor(level).name {
+	case "DEBUG":
+		return f.Theme.Debug
+	case "INFO":
+		return f.Theme.Info
+	case "WARN":
+		return f.Theme.Warn
+	case "ERROR":
+		return f.Theme.Error
+	default:
+		return f.Theme.White
+	}
+}
+
+func appendValue(buf []byte, v slog.Value, th Theme) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return append(buf, v.String()...)
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		return strconv.AppendFloat(buf, v.Float64(), 'f', -1, 64)
+	case slog.KindDuration:
+		return appendDuration(buf, v.Duration())
+	case slog.KindTime:
+		return v.Time().AppendFormat(buf, time.RFC3339Nano)
+	case slog.KindGroup:
+		attrs := v.Group()
+		buf = append(buf, th.Reset...)
+		buf = append(buf, th.Purple...)
+		buf = append(buf, '(')
+		for i, attr := range attrs {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = append(buf, th.Reset...)
+			buf = append(buf, th.Key...)
+			buf = append(buf, attr.Key...)
+			buf = append(buf, th.Reset...)
+			buf = append(buf, '=')
+			buf = append(buf, th.Value...)
+			buf = appendValue(buf, attr.Value, th)
+			buf = append(buf, th.Reset...)
+		}
+		buf = append(buf, th.Purple...)
+		buf = append(buf, ')')
+		buf = append(buf, th.Reset...)
+		return buf
+	case slog.KindAny:
+		if bs, ok := byteSlice(v.Any()); ok {
+			return append(buf, bs...)
+		}
+		return fmt.Append(buf, v.Any())
+	default:
+		return append(buf, v.String()...)
+	}
+}
+
+func byteSlice(a any) ([]byte, bool) {
+	if bs, ok := a.([]byte); ok {
+		return bs, true
+	}
+
+	t := reflect.TypeOf(a)
+	if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return reflect.ValueOf(a).Bytes(), true
+	}
+	return nil, false
+}
+
+func appendDuration(buf []byte, d time.Duration) []byte {
+	if d == 0 {
+		return append(buf, "0s"...)
+	}
+
+	neg := d < 0
+	if neg {
+		d = -d
+	}
+
+	u := uint64(d)
+
+	if u < uint64(time.Second) {
+		buf = strconv.AppendFloat(buf, float64(u)/1000000, 'f', -1, 64)
+		buf = append(buf, 'm')
+		buf = append(buf, 's')
+	} else {
+		secs := u / uint64(time.Second)
+		nsecs := u % uint64(time.Second)
+
+		buf = strconv.AppendUint(buf, secs, 10)
+
+		if nsecs > 0 {
+			buf = append(buf, '.')
+			var nsBuf [9]byte
+			ns := strconv.AppendUint(nsBuf[:0], nsecs, 10)
+			for i := 0; i < 9-len(ns); i++ {
+				buf = append(buf, '0')
+			}
+			buf = append(buf, ns...)
+		}
+
+		buf = append(buf, 's')
+	}
+
+	if neg {
+		buf = append([]byte{'-'}, buf...)
+	}
+
+	return buf
+}
@@ -0,0 +1,88 @@
+package beautyslog
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+)
+
+// stackTrace looks for a stack trace on err or anything it wraps,
+// following the convention used by github.com/pkg/errors and compatible
+// packages: an error exposing `StackTrace() errors.StackTrace`, where
+// errors.StackTrace is a slice whose element type is a uintptr-based
+// program counter. The return type can't be named without depending on
+// that package, so the match is duck-typed via reflection.
+func stackTrace(err error) ([]uintptr, bool) {
+	for err != nil {
+		if pcs, ok := tracerPCs(err); ok {
+			return pcs, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// tracerPCs extracts the program counters from err's StackTrace method,
+// if it has one matching the pkg/errors shape.
+func tracerPCs(err error) ([]uintptr, bool) {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil, false
+	}
+
+	out := m.Call(nil)[0]
+	if out.Kind() != reflect.Slice || out.Type().Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+
+	pcs := make([]uintptr, out.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(out.Index(i).Uint())
+	}
+	return pcs, true
+}
+
+// callerStack captures the current call stack, for StackTracesAlways
+// records whose error attributes don't already carry one. The skip
+// count walks past callerStack, AppendRecord, Handle, and the two
+// log/slog frames above Handle (the Logger method, e.g. Error, and the
+// internal log method it calls), so the trace starts at the actual slog
+// call site instead of inside this package.
+func callerStack() []uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(6, pcs[:])
+	return pcs[:n]
+}
+
+// appendStackTrace renders pcs as indented, colorized frames underneath
+// the current line, one per line: "  <Purple>func<Reset> <Time>file:line<Reset>".
+func appendStackTrace(buf []byte, pcs []uintptr, th Theme) []byte {
+	if len(pcs) == 0 {
+		return buf
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+
+		buf = append(buf, '\n', ' ', ' ')
+		buf = append(buf, th.Purple...)
+		buf = append(buf, frame.Function...)
+		buf = append(buf, th.Reset...)
+		buf = append(buf, ' ')
+		buf = append(buf, th.Time...)
+		buf = append(buf, frame.File...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+		buf = append(buf, th.Reset...)
+
+		if !more {
+			break
+		}
+	}
+	return buf
+}
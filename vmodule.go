@@ -0,0 +1,130 @@
+package beautyslog
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// vmoduleRule is one pattern=level pair from a Vmodule spec, with the
+// pattern pre-split into path segments for glob matching.
+type vmoduleRule struct {
+	segments []string
+	pattern  string
+	level    slog.Level
+}
+
+// matches reports whether file's trailing path components match the
+// rule's pattern, e.g. pattern "db/*" matches ".../somepkg/db/conn.go".
+func (r vmoduleRule) matches(file string) bool {
+	fileSegs := strings.Split(file, "/")
+	if len(r.segments) > len(fileSegs) {
+		return false
+	}
+	suffix := strings.Join(fileSegs[len(fileSegs)-len(r.segments):], "/")
+	ok, err := path.Match(r.pattern, suffix)
+	return err == nil && ok
+}
+
+// vmoduleDecision is the cached outcome of matching one PC against all
+// rules: either "no rule matched" or the level of the first rule that did.
+type vmoduleDecision struct {
+	level   slog.Level
+	matched bool
+}
+
+// vmoduleMatcher holds compiled Vmodule rules and memoizes the decision
+// per call site (slog.Record.PC) so the hot path is a single map lookup.
+type vmoduleMatcher struct {
+	rules    []vmoduleRule
+	minLevel slog.Level
+	cache    sync.Map // uintptr -> vmoduleDecision
+}
+
+func newVmoduleMatcher(rules []vmoduleRule, globalLevel slog.Level) *vmoduleMatcher {
+	minLevel := globalLevel
+	for _, r := range rules {
+		if r.level < minLevel {
+			minLevel = r.level
+		}
+	}
+	return &vmoduleMatcher{rules: rules, minLevel: minLevel}
+}
+
+// levelFor returns the vmodule-overridden level for pc's source file, if
+// any rule matches it.
+func (m *vmoduleMatcher) levelFor(pc uintptr) (slog.Level, bool) {
+	if v, ok := m.cache.Load(pc); ok {
+		d := v.(vmoduleDecision)
+		return d.level, d.matched
+	}
+
+	var decision vmoduleDecision
+	if file, ok := sourceFile(pc); ok {
+		for _, r := range m.rules {
+			if r.matches(file) {
+				decision = vmoduleDecision{level: r.level, matched: true}
+				break
+			}
+		}
+	}
+	m.cache.Store(pc, decision)
+	return decision.level, decision.matched
+}
+
+// sourceFile resolves pc to its full source path (unlike resolveSource,
+// the directory components are kept so patterns like "db/*" can match).
+func sourceFile(pc uintptr) (string, bool) {
+	if pc == 0 {
+		return "", false
+	}
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	return f.File, f.File != ""
+}
+
+// parseVmodule parses a comma-separated "pattern=level" spec, e.g.
+// "db/*=debug,cache.go=warn".
+func parseVmodule(spec string) ([]vmoduleRule, error) {
+	var rules []vmoduleRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, levelStr, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("beautyslog: invalid vmodule entry %q: want pattern=level", entry)
+		}
+		pattern = strings.TrimSpace(pattern)
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(levelStr))); err != nil {
+			return nil, fmt.Errorf("beautyslog: invalid vmodule entry %q: %w", entry, err)
+		}
+		rules = append(rules, vmoduleRule{
+			segments: strings.Split(pattern, "/"),
+			pattern:  pattern,
+			level:    level,
+		})
+	}
+	return rules, nil
+}
+
+// Vmodule installs per-file/per-package verbosity overrides, replacing
+// any previous rules. spec is a comma-separated list of pattern=level
+// pairs, e.g. "db/*=debug,cache.go=warn": a log site whose source file
+// matches pattern is filtered against level instead of Options.Level.
+// Patterns match the trailing path components of the caller's file,
+// glob-style (see path.Match). Rules are shared with every handler
+// derived from h via WithAttrs/WithGroup.
+func (h *PrettyTextHandler) Vmodule(spec string) error {
+	rules, err := parseVmodule(spec)
+	if err != nil {
+		return err
+	}
+	h.vmod.Store(newVmoduleMatcher(rules, h.opts.Level.Level()))
+	return nil
+}